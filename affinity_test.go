@@ -0,0 +1,139 @@
+package routinepool
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stallOtherWorkers 让除 target 外的所有 worker 在整个测试期间都阻塞在
+// sibUnblock 上执行占位任务，使它们既不空闲、也就没有机会从 target 的
+// 队列里把子任务行窃走。target 自己先用 targetGuard 上的一个占位任务
+// 临时占住，避免它在各 sibling 还没来得及把占位任务取走执行（此时队列
+// 里还有东西可偷）之前就提前进入空闲状态去行窃；等所有 sibling 都已经
+// 把占位任务取出来阻塞执行、队列归零之后再放行 target，这时 target 和
+// 各 sibling 的队列长度都是 0，leastLoaded 在长度相同时保留 workers[0]，
+// 也就是 target，从而保证后续提交的根任务必然落在 target 上。
+func stallOtherWorkers(t *testing.T, pool *Pool, target *worker) (sibUnblock chan struct{}) {
+	t.Helper()
+	sibUnblock = make(chan struct{})
+	targetGuard := make(chan struct{})
+
+	pool.workersMu.Lock()
+	others := make([]*worker, 0, len(pool.workers)-1)
+	for _, w := range pool.workers {
+		if w != target {
+			others = append(others, w)
+		}
+	}
+	pool.workersMu.Unlock()
+
+	if !target.queue.pushBack(Task{index: -1, fn: func() (interface{}, error) {
+		<-targetGuard
+		return nil, nil
+	}}) {
+		t.Fatalf("failed to seed the guard task into target's own queue")
+	}
+	target.wake()
+
+	for _, w := range others {
+		if !w.queue.pushBack(Task{index: -1, fn: func() (interface{}, error) {
+			<-sibUnblock
+			return nil, nil
+		}}) {
+			t.Fatalf("failed to seed filler task into a sibling worker's queue")
+		}
+		w.wake()
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		allSettled := true
+		for _, w := range others {
+			if w.queue.len() != 0 {
+				allSettled = false
+				break
+			}
+		}
+		if allSettled {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for sibling workers to pick up their filler tasks")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(targetGuard)
+
+	deadline = time.Now().Add(2 * time.Second)
+	for {
+		if target.queue.len() == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for target's guard task to finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	return sibUnblock
+}
+
+// 验证亲和性路由通过真实的 AddTask 路径生效：在某个 worker 自己执行的
+// 任务内部提交的子任务，应当投递回同一个 worker 的本地队列并在它上面执行，
+// 而不是被 leastLoaded 打散到其它 worker 上。其它 worker 在整个测试期间
+// 都被占位任务占住、无法空闲下来行窃，从而可以确定性地断言 100% 命中，
+// 而不必依赖行窃不发生的运气。
+func TestNestedAddTaskRoutesBackToSubmittingWorker(t *testing.T) {
+	pool := NewPool(4, 1000)
+	if err := pool.Start(); err != nil {
+		t.Fatalf("start pool: %v", err)
+	}
+	defer pool.Stop()
+
+	pool.workersMu.Lock()
+	target := pool.workers[0]
+	pool.workersMu.Unlock()
+
+	sibUnblock := stallOtherWorkers(t, pool, target)
+	defer close(sibUnblock)
+
+	const n = 50
+	future := pool.newFuture(n + 1)
+	defer future.Close()
+
+	var parentWorkerID int32 = -1
+	var matches int32
+
+	if err := pool.AddTask(future, 0, func() (interface{}, error) {
+		if w := pool.affinityWorker(); w != nil {
+			atomic.StoreInt32(&parentWorkerID, int32(w.id))
+		}
+		for i := 1; i <= n; i++ {
+			idx := i
+			if err := pool.AddTask(future, idx, func() (interface{}, error) {
+				if w := pool.affinityWorker(); w != nil && atomic.LoadInt32(&parentWorkerID) == int32(w.id) {
+					atomic.AddInt32(&matches, 1)
+				}
+				return idx, nil
+			}); err != nil {
+				t.Error(err)
+			}
+		}
+		return 0, nil
+	}); err != nil {
+		t.Fatalf("add root task: %v", err)
+	}
+
+	for i := 0; i <= n; i++ {
+		<-future.Result
+	}
+
+	if atomic.LoadInt32(&parentWorkerID) != int32(target.id) {
+		t.Fatalf("expected the root task to run on the pre-selected idle worker %d, ran on %d", target.id, parentWorkerID)
+	}
+	if got := atomic.LoadInt32(&matches); got != n {
+		t.Errorf("expected all %d nested tasks to route back to the submitting worker, got %d", n, got)
+	}
+}
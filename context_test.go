@@ -0,0 +1,114 @@
+package routinepool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stallSingleWorkerQueue 用一堆阻塞在 unblock 上的占位任务把单 worker 协程池
+// 的本地队列塞满，使 dispatch 卡在向其投递任务上。调用方在此期间通过该
+// pool 提交的任务会安全地积压在优先级队列里，不会被提前取走执行，从而让
+// 调度顺序、取消语义等行为可以被确定性地观察，而不必依赖 sleep 赌时序。
+func stallSingleWorkerQueue(t *testing.T, pool *Pool) (fillerFuture *Future, unblock chan struct{}) {
+	t.Helper()
+	unblock = make(chan struct{})
+	fillerCount := workerQueueCapacity + 2 // 1 个被 worker 取走执行 + 塞满队列 + 1 个溢出触发拥塞重试
+	fillerFuture = pool.newFuture(fillerCount)
+	for i := 0; i < fillerCount; i++ {
+		idx := i
+		if err := pool.AddTask(fillerFuture, idx, func() (interface{}, error) {
+			<-unblock
+			return nil, nil
+		}); err != nil {
+			t.Fatalf("seed filler task %d: %v", idx, err)
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		pool.workersMu.Lock()
+		w := pool.workers[0]
+		pool.workersMu.Unlock()
+		// 队列满且优先级队列已排空，说明最后一个溢出的占位任务已经被 dispatch
+		// 取出、正卡在投递重试上，调用方提交的任务才能放心地堆积在 pq 里
+		if w.queue.len() == workerQueueCapacity && pool.pq.len() == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for the worker's local queue to fill up")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return fillerFuture, unblock
+}
+
+// ctx 在成功入队之前就被取消时，AddTaskContext 应当直接返回 ctx.Err()，fn 不会运行
+func TestAddTaskContextCanceledBeforeEnqueueReturnsImmediately(t *testing.T) {
+	pool := NewPool(1, 10)
+	if err := pool.Start(); err != nil {
+		t.Fatalf("start pool: %v", err)
+	}
+	defer pool.Stop()
+
+	future := pool.newFuture(1)
+	defer future.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var ran int32
+	err := pool.AddTaskContext(ctx, future, 0, func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&ran, 1)
+		return nil, nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected AddTaskContext to return context.Canceled for an already-canceled ctx, got %v", err)
+	}
+	if atomic.LoadInt32(&ran) != 0 {
+		t.Errorf("expected fn not to run when ctx was canceled before enqueue, got ran=%d", ran)
+	}
+}
+
+// ctx 在任务已经入队、但还没被 dispatch 取出投递给 worker 前被取消时，
+// dispatch 应当直接丢弃该任务并回传 ctx.Err()，fn 不会运行
+func TestAddTaskContextCanceledWhileQueuedIsDropped(t *testing.T) {
+	pool := NewPool(1, workerQueueCapacity+20)
+	if err := pool.Start(); err != nil {
+		t.Fatalf("start pool: %v", err)
+	}
+	defer pool.Stop()
+
+	_, unblock := stallSingleWorkerQueue(t, pool)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	resultFuture := pool.newFuture(1)
+	defer resultFuture.Close()
+
+	var ran int32
+	if err := pool.AddTaskContext(ctx, resultFuture, 0, func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&ran, 1)
+		return nil, nil
+	}); err != nil {
+		t.Fatalf("add context task while the queue is stalled: %v", err)
+	}
+	// 任务此时已经入队，但 dispatch 还卡在投递前面的占位任务上，还没机会
+	// 把它从优先级队列里取出来，这里取消它应当在被取出时就被丢弃
+	cancel()
+
+	close(unblock)
+
+	select {
+	case r := <-resultFuture.Result:
+		if !errors.Is(r.err, context.Canceled) {
+			t.Errorf("expected the dropped task to resolve with context.Canceled, got %v", r.err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the canceled task to resolve")
+	}
+	if atomic.LoadInt32(&ran) != 0 {
+		t.Errorf("expected fn not to run once ctx was canceled while queued, got ran=%d", ran)
+	}
+}
@@ -0,0 +1,59 @@
+package routinepool
+
+import "sync"
+
+// workerQueueCapacity 每个 worker 本地队列的容量上限，超出后投递方视为拥塞
+const workerQueueCapacity = 256
+
+// taskDeque 是 worker 的本地任务队列：worker 自己从队头取任务（FIFO），
+// 其它 worker 在行窃时从队尾取任务，减少两者同时操作同一端的争用
+type taskDeque struct {
+	mu    sync.Mutex
+	tasks []Task
+}
+
+func newTaskDeque() *taskDeque {
+	return &taskDeque{tasks: make([]Task, 0, workerQueueCapacity)}
+}
+
+// pushBack 将任务追加到队尾，超过 workerQueueCapacity 时返回 false
+func (d *taskDeque) pushBack(t Task) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.tasks) >= workerQueueCapacity {
+		return false
+	}
+	d.tasks = append(d.tasks, t)
+	return true
+}
+
+// popFront 由队列的所有者 worker 调用，取走最早入队的任务
+func (d *taskDeque) popFront() (Task, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.tasks) == 0 {
+		return Task{}, false
+	}
+	t := d.tasks[0]
+	d.tasks = d.tasks[1:]
+	return t, true
+}
+
+// popBack 由窃取任务的其它 worker 调用，取走最晚入队的任务
+func (d *taskDeque) popBack() (Task, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := len(d.tasks)
+	if n == 0 {
+		return Task{}, false
+	}
+	t := d.tasks[n-1]
+	d.tasks = d.tasks[:n-1]
+	return t, true
+}
+
+func (d *taskDeque) len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.tasks)
+}
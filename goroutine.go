@@ -0,0 +1,24 @@
+package routinepool
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+)
+
+// goroutineID 解析当前 goroutine 的 id，仅用于识别"任务是否在某个 worker
+// 自己的协程内提交"，从而实现亲和性投递；不用于其它同步语义
+func goroutineID() uint64 {
+	buf := make([]byte, 64)
+	n := runtime.Stack(buf, false)
+	buf = buf[:n]
+	fields := bytes.Fields(buf)
+	if len(fields) < 2 {
+		return 0
+	}
+	id, err := strconv.ParseUint(string(fields[1]), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
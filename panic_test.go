@@ -0,0 +1,54 @@
+package routinepool
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// 验证任务 panic 后 worker 能够恢复并继续服务后续任务，同时 PanicHandler 会被调用
+func TestWorkerRecoversFromPanicAndKeepsServing(t *testing.T) {
+	var handled int32
+	var handledIndex int32 = -1
+	pool := NewPool(1, 10, WithPanicHandler(func(taskIndex int, r interface{}) {
+		atomic.AddInt32(&handled, 1)
+		atomic.StoreInt32(&handledIndex, int32(taskIndex))
+	}))
+	if err := pool.Start(); err != nil {
+		t.Fatalf("start pool: %v", err)
+	}
+	defer pool.Stop()
+
+	future := pool.newFuture(2)
+	defer future.Close()
+
+	if err := pool.AddTask(future, 0, func() (interface{}, error) {
+		panic("boom")
+	}); err != nil {
+		t.Fatalf("add panicking task: %v", err)
+	}
+	if err := pool.AddTask(future, 1, func() (interface{}, error) {
+		return "ok", nil
+	}); err != nil {
+		t.Fatalf("add follow-up task: %v", err)
+	}
+
+	results := make(map[int]Result, 2)
+	for i := 0; i < 2; i++ {
+		r := <-future.Result
+		results[r.index] = r
+	}
+
+	if results[0].err == nil || !strings.Contains(results[0].err.Error(), "panic") {
+		t.Errorf("expected panicking task to resolve with a panic error, got %v", results[0].err)
+	}
+	if results[1].err != nil || results[1].value != "ok" {
+		t.Errorf("expected the worker to keep serving after a panic, got value=%v err=%v", results[1].value, results[1].err)
+	}
+	if atomic.LoadInt32(&handled) != 1 {
+		t.Errorf("expected PanicHandler to run exactly once, got %d", handled)
+	}
+	if atomic.LoadInt32(&handledIndex) != 0 {
+		t.Errorf("expected PanicHandler to receive task index 0, got %d", handledIndex)
+	}
+}
@@ -1,16 +1,61 @@
 package routinepool
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"math/rand"
+	"net/http"
 	"sync"
 	"sync/atomic"
+	"time"
+)
+
+// 协程池扩缩容相关的默认参数
+const (
+	// defaultIdleTimeout 工作协程空闲超过该时长后会退出，使协程池收缩回 min
+	defaultIdleTimeout = 30 * time.Second
+	// dispatchCongestionTimeout 所有 worker 本地队列都已满、且扩容失败
+	// （已达 max）时，deliver 退避多久后再重试投递
+	dispatchCongestionTimeout = 5 * time.Millisecond
 )
 
 // Task 任务结构体，包含任务的唯一索引、具体任务函数和响应通道
 type Task struct {
 	index      int
 	fn         func() (interface{}, error)
+	ctxFn      func(ctx context.Context) (interface{}, error)
+	ctx        context.Context
 	responseCh chan Result
+	enqueuedAt time.Time
+
+	// affinityWorker 是提交本任务时所在的 worker：如果 AddTask 系列方法是
+	// 在某个 worker 自己正在执行的任务内部调用的，这里会在提交方（也就是
+	// 该 worker）自己的协程上捕获到它，供 deliver 优先投递回同一个 worker。
+	// 必须在提交时捕获，而不是等到 dispatch 协程再去查——dispatch 运行在
+	// 自己独立的协程上，那时查到的 goroutine id 是 dispatch 的，不是提交者的。
+	affinityWorker *worker
+}
+
+// PanicHandler 用于处理任务执行过程中发生的 panic，taskIndex 为任务索引
+type PanicHandler func(taskIndex int, r interface{})
+
+// Option 用于配置 Pool 的可选参数
+type Option func(*Pool)
+
+// WithPanicHandler 设置任务 panic 时的回调，worker 会在捕获 panic 后继续服务下一个任务
+func WithPanicHandler(h PanicHandler) Option {
+	return func(p *Pool) {
+		p.panicHandler = h
+	}
+}
+
+// WithStarvationPrevention 设置连续放行多少个"存在更低优先级任务排队"的高
+// 优先级任务后，强制放行一个当前排队中优先级最低的任务，避免其被饿死
+func WithStarvationPrevention(maxSkip int) Option {
+	return func(p *Pool) {
+		p.pq.maxSkip = maxSkip
+	}
 }
 
 type Result struct {
@@ -23,49 +68,131 @@ type Future struct {
 	Result chan Result
 }
 
-// Pool 协程池结构体
+// Stats 协程池运行状态统计
+type Stats struct {
+	QueuedTasks    int64 // 尚未被 dispatch 取走的任务数加上所有 worker 本地队列里排队的任务数
+	RunningTasks   int64 // 正在执行任务的 worker 数
+	Idle           int64 // 空闲等待任务的 worker 数
+	CompletedTasks int64 // 成功完成（未返回 error）的任务数
+	FailedTasks    int64 // 返回 error（含 panic 恢复）的任务数
+	PanicCount     int64 // 发生 panic 并被恢复的任务数
+	Steals         int64 // 发生行窃的总次数
+	TotalWaitNanos int64 // 所有任务从进入优先级队列到开始执行的等待耗时总和（纳秒），不含 AddTask 等待排队槽位的时间
+	TotalRunNanos  int64 // 所有任务实际执行耗时总和（纳秒）
+}
+
+// MetricsSink 允许调用方把协程池事件桥接到外部监控系统（如 Prometheus/OpenTelemetry）
+type MetricsSink interface {
+	OnEnqueue()
+	OnStart()
+	OnFinish(err error)
+}
+
+// WithMetricsSink 注册一个 MetricsSink，协程池会在任务入队、开始执行、结束时回调它
+func WithMetricsSink(sink MetricsSink) Option {
+	return func(p *Pool) {
+		p.metricsSink = sink
+	}
+}
+
+// Pool 协程池结构体，支持在 [min, max] 区间内按需扩缩容
 type Pool struct {
-	numWorkers int
-	taskCh     chan Task
-	workers    []*worker
+	minWorkers   int
+	maxWorkers   int
+	idleTimeout  time.Duration
+	panicHandler PanicHandler
+	metricsSink  MetricsSink
+
+	pq         *priorityQueue
 	closed     atomic.Bool
 	wg         sync.WaitGroup
 	shutdownCh chan struct{}
+
+	// workersMu 保护 workers 与 nextID，因为扩容/缩容会并发修改切片
+	workersMu sync.Mutex
+	workers   []*worker
+	nextID    int
+
+	// affinity 记录 "协程 id -> 正在执行任务的 worker"，使得在某个 worker
+	// 自己的任务里再次提交任务时，能够优先投递回同一个 worker 的本地队列
+	affinity sync.Map
+
+	running   atomic.Int64
+	idle      atomic.Int64
+	steals    atomic.Int64
+	completed atomic.Int64
+	failed    atomic.Int64
+	panics    atomic.Int64
+	waitNanos atomic.Int64
+	runNanos  atomic.Int64
 }
 
-// NewPool 创建一个新的协程池
-func NewPool(numWorkers int, taskChSize int) *Pool {
-	if taskChSize <= 0 {
-		taskChSize = 1
+// NewPool 创建一个固定大小的协程池，等价于 min = max = numWorkers
+func NewPool(numWorkers int, taskChSize int, opts ...Option) *Pool {
+	return NewPoolWithBounds(numWorkers, numWorkers, taskChSize, opts...)
+}
+
+// NewPoolWithBounds 创建一个可在 [min, max] 区间内按需扩缩容的协程池。
+// taskChSize 是等待被 dispatch 取走的任务队列容量，队列写满后 AddTask 系列
+// 方法会阻塞（AddTaskContext 额外响应 ctx 取消），提供背压。
+// 当任务投递出现拥塞时向 max 方向扩容，worker 空闲超过 idleTimeout 后向 min 方向收缩。
+func NewPoolWithBounds(min, max int, taskChSize int, opts ...Option) *Pool {
+	if min <= 0 {
+		min = 1
+	}
+	if max < min {
+		max = min
 	}
 	p := &Pool{
-		numWorkers: numWorkers,
-		taskCh:     make(chan Task, taskChSize),
-		workers:    make([]*worker, numWorkers),
-		shutdownCh: make(chan struct{}),
+		minWorkers:  min,
+		maxWorkers:  max,
+		idleTimeout: defaultIdleTimeout,
+		pq:          newPriorityQueue(taskChSize),
+		shutdownCh:  make(chan struct{}),
 	}
 	p.closed.Store(true)
+	for _, opt := range opts {
+		opt(p)
+	}
 
-	for i := 0; i < numWorkers; i++ {
-		w := &worker{
-			id:        i,
-			owner:     p,
-			requestCh: make(chan Task),
-			shutdown:  make(chan struct{}),
-		}
-		p.workers[i] = w
+	p.workers = make([]*worker, 0, max)
+	for i := 0; i < min; i++ {
+		p.workers = append(p.workers, p.newWorker())
 	}
 	return p
 }
 
+// SetIdleTimeout 设置空闲多久后收缩 worker，需要在 Start 之前调用
+func (p *Pool) SetIdleTimeout(d time.Duration) {
+	p.idleTimeout = d
+}
+
+// newWorker 创建一个归属于当前 Pool 的 worker，调用方需持有 workersMu
+func (p *Pool) newWorker() *worker {
+	w := &worker{
+		id:       p.nextID,
+		owner:    p,
+		queue:    newTaskDeque(),
+		wakeCh:   make(chan struct{}, 1),
+		shutdown: make(chan struct{}),
+	}
+	p.nextID++
+	return w
+}
+
 // Start 启动协程池
 func (p *Pool) Start() error {
 	if !p.closed.CompareAndSwap(true, false) {
 		return fmt.Errorf("the pool is already running")
 	}
+	p.workersMu.Lock()
+	workers := append([]*worker(nil), p.workers...)
+	p.workersMu.Unlock()
+
 	// wg 用于等待 dispatch 和 所有 worker 退出
-	p.wg.Add(p.numWorkers + 1)
-	for _, w := range p.workers {
+	p.wg.Add(len(workers) + 1)
+	p.idle.Add(int64(len(workers)))
+	for _, w := range workers {
 		go w.start()
 	}
 	go p.dispatch()
@@ -79,13 +206,46 @@ func (p *Pool) newFuture(size int) *Future {
 	}
 }
 
-// AddTask 向协程池添加任务，接收调用方传入的响应通道
+// AddTask 向协程池添加任务，优先级默认为 0
 func (p *Pool) AddTask(future *Future, index int, fn func() (interface{}, error)) error {
+	return p.AddTaskPriority(future, index, 0, fn)
+}
+
+// AddTaskPriority 向协程池添加一个带优先级的任务，priority 越大越先被调度；
+// 同一 priority 内部保持先进先出。background 任务可使用较低的 priority，
+// 以便更紧急的任务能够插队执行。队列已满（达到 taskChSize）时本方法会阻塞。
+func (p *Pool) AddTaskPriority(future *Future, index int, priority int, fn func() (interface{}, error)) error {
+	if p.closed.Load() {
+		return fmt.Errorf("the pool is closed")
+	}
+	task := Task{index: index, fn: fn, responseCh: future.Result, affinityWorker: p.affinityWorker()}
+	if !p.pq.push(task, priority) {
+		return fmt.Errorf("the pool is closed")
+	}
+	if p.metricsSink != nil {
+		p.metricsSink.OnEnqueue()
+	}
+	return nil
+}
+
+// AddTaskContext 向协程池添加一个受 ctx 控制的任务，优先级默认为 0。
+// 队列已满时会阻塞在入队上，如果在成功入队之前 ctx 被取消，直接返回 ctx.Err()；
+// 如果任务已经入队但在被 worker 执行前 ctx 被取消，dispatch 会丢弃该任务
+// 并向 future.Result 投递 Result{err: ctx.Err()}，而不会真正执行 fn。
+func (p *Pool) AddTaskContext(ctx context.Context, future *Future, index int, fn func(ctx context.Context) (interface{}, error)) error {
 	if p.closed.Load() {
 		return fmt.Errorf("the pool is closed")
 	}
-	task := Task{index: index, fn: fn, responseCh: future.Result}
-	p.taskCh <- task
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	task := Task{index: index, ctxFn: fn, ctx: ctx, responseCh: future.Result, affinityWorker: p.affinityWorker()}
+	if err := p.pq.pushCtx(ctx, task, 0); err != nil {
+		return err
+	}
+	if p.metricsSink != nil {
+		p.metricsSink.OnEnqueue()
+	}
 	return nil
 }
 
@@ -94,33 +254,217 @@ func (p *Pool) Stop() error {
 	if !p.closed.CompareAndSwap(false, true) {
 		return fmt.Errorf("the pool is already closed")
 	}
-	close(p.taskCh)
+	p.pq.close()
 	close(p.shutdownCh)
-	for _, w := range p.workers {
-		close(w.shutdown)
+	p.workersMu.Lock()
+	workers := p.workers
+	p.workersMu.Unlock()
+	for _, w := range workers {
+		w.stop()
 	}
 	p.wg.Wait()
 	return nil
 }
 
-// dispatch 调度任务给工作协程
+// Stats 返回协程池当前的运行状态
+func (p *Pool) Stats() Stats {
+	p.workersMu.Lock()
+	workers := p.workers
+	p.workersMu.Unlock()
+
+	// QueuedTasks 既要算上还没被 dispatch 取走的任务（p.pq），
+	// 也要算上已经投递进某个 worker 本地队列、但尚未开始执行的任务
+	depth := int64(p.pq.len())
+	for _, w := range workers {
+		depth += int64(w.queue.len())
+	}
+	return Stats{
+		QueuedTasks:    depth,
+		RunningTasks:   p.running.Load(),
+		Idle:           p.idle.Load(),
+		CompletedTasks: p.completed.Load(),
+		FailedTasks:    p.failed.Load(),
+		PanicCount:     p.panics.Load(),
+		Steals:         p.steals.Load(),
+		TotalWaitNanos: p.waitNanos.Load(),
+		TotalRunNanos:  p.runNanos.Load(),
+	}
+}
+
+// ServeHTTP 实现一个可选的 /debug/pool 处理器，以 JSON 形式输出 Stats
+func (p *Pool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(p.Stats()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// grow 在未达到 maxWorkers 时新增一个 worker，用于缓解投递拥塞
+func (p *Pool) grow() {
+	if p.closed.Load() {
+		return
+	}
+	p.workersMu.Lock()
+	if len(p.workers) >= p.maxWorkers {
+		p.workersMu.Unlock()
+		return
+	}
+	w := p.newWorker()
+	p.workers = append(p.workers, w)
+	p.workersMu.Unlock()
+
+	p.wg.Add(1)
+	p.idle.Add(1)
+	go w.start()
+}
+
+// steal 为空闲的 self 从一个随机位置开始依次查看其它 worker，
+// 从第一个本地队列非空的 worker 队尾偷一个任务
+func (p *Pool) steal(self *worker) (Task, bool) {
+	p.workersMu.Lock()
+	workers := p.workers
+	p.workersMu.Unlock()
+	if len(workers) <= 1 {
+		return Task{}, false
+	}
+	start := rand.Intn(len(workers))
+	for i := 0; i < len(workers); i++ {
+		peer := workers[(start+i)%len(workers)]
+		if peer == self {
+			continue
+		}
+		if task, ok := peer.queue.popBack(); ok {
+			p.steals.Add(1)
+			return task, true
+		}
+	}
+	return Task{}, false
+}
+
+// shrink 在不低于 minWorkers 的前提下将 w 从 workers 中移除，返回是否移除成功。
+// 移除前必须在持有 workersMu 期间重新确认 w 的本地队列确实为空——deliver
+// 对同一把锁做的是"读 workers、选目标、pushBack"一次性持锁完成，所以如果
+// 就在这次收缩之前已经有任务被投递进 w（还没来得及被 w 自己取走或被行窃），
+// w.queue.len() 在这里一定能看到，从而放弃这次收缩，避免任务残留在一个
+// 即将从 p.workers 里消失、从此再没有任何人（包括 steal）会去看的队列里
+func (p *Pool) shrink(w *worker) bool {
+	p.workersMu.Lock()
+	defer p.workersMu.Unlock()
+	if len(p.workers) <= p.minWorkers {
+		return false
+	}
+	if w.queue.len() > 0 {
+		return false
+	}
+	for i, cur := range p.workers {
+		if cur == w {
+			// 重新分配一份新的底层数组，而不是在原数组上 append，
+			// 否则会和只持锁复制切片头、随后在锁外遍历/解引用的读者
+			// （steal、Stats、Stop）产生数据竞争
+			newWorkers := make([]*worker, 0, len(p.workers)-1)
+			newWorkers = append(newWorkers, p.workers[:i]...)
+			newWorkers = append(newWorkers, p.workers[i+1:]...)
+			p.workers = newWorkers
+			return true
+		}
+	}
+	return false
+}
+
+// dispatch 从优先级队列取出任务并投递到某个 worker 的本地队列
 func (p *Pool) dispatch() {
 	defer p.wg.Done()
-	taskIndex := 0
 	for {
-		select {
-		case task, ok := <-p.taskCh:
-			if !ok {
-				// 任务通道关闭，可能是 Stop 函数调用导致
-				return
+		task, ok := p.pq.pop()
+		if !ok {
+			// 队列已关闭且排空，说明 Stop 函数调用导致
+			return
+		}
+		if task.ctx != nil && task.ctx.Err() != nil {
+			// 任务在队列中等待期间 ctx 已被取消，丢弃并直接回传错误
+			if task.responseCh != nil {
+				task.responseCh <- Result{index: task.index, err: task.ctx.Err()}
+			}
+			continue
+		}
+		p.deliver(task)
+	}
+}
+
+// deliver 把任务投递到提交方的 affinity worker（task.affinityWorker，在提交
+// 时由提交者自己的协程捕获，而不是在这里重新查——dispatch 运行在自己独立
+// 的协程上，查不到提交者的 affinity），否则投递到当前负载最小的 worker；
+// 所有本地队列都已满且已达 maxWorkers 时，按 dispatchCongestionTimeout 退避重试。
+// "选中目标 worker"和"pushBack 进它的队列"必须在同一次持有 workersMu 期间
+// 完成，不能先拷贝一份 workers 切片、释放锁、再对切片里的 worker 做操作——
+// 否则 shrink 可能恰好在这个窗口期把被选中的 worker 从 p.workers 里摘掉，
+// 使这次投递的任务进了一个从此没有任何人会再去看的队列，永远得不到执行
+func (p *Pool) deliver(task Task) {
+	if w := task.affinityWorker; w != nil {
+		p.workersMu.Lock()
+		active := false
+		for _, cur := range p.workers {
+			if cur == w {
+				active = true
+				break
 			}
-			workerIndex := taskIndex % p.numWorkers
-			p.workers[workerIndex].requestCh <- task
-			taskIndex++
-		case <-p.shutdownCh:
+		}
+		ok := active && w.queue.pushBack(task)
+		p.workersMu.Unlock()
+		if ok {
+			w.wake()
+			return
+		}
+	}
+	for {
+		p.workersMu.Lock()
+		if len(p.workers) == 0 {
+			p.workersMu.Unlock()
+			p.grow()
+			continue
+		}
+		target := leastLoaded(p.workers)
+		if target.queue.pushBack(task) {
+			p.workersMu.Unlock()
+			target.wake()
 			return
 		}
+		// 目标队列已满，视为拥塞：先尝试扩容，扩不动就退避后重试
+		before := len(p.workers)
+		p.workersMu.Unlock()
+		p.grow()
+		p.workersMu.Lock()
+		grew := len(p.workers) > before
+		p.workersMu.Unlock()
+		if !grew {
+			select {
+			case <-time.After(dispatchCongestionTimeout):
+			case <-p.shutdownCh:
+				return
+			}
+		}
+	}
+}
+
+// affinityWorker 返回当前协程所属的 worker（如果当前协程本身就是某个 worker
+// 正在执行任务的协程）
+func (p *Pool) affinityWorker() *worker {
+	if v, ok := p.affinity.Load(goroutineID()); ok {
+		return v.(*worker)
+	}
+	return nil
+}
+
+// leastLoaded 从 workers 中选出本地队列最短的一个
+func leastLoaded(workers []*worker) *worker {
+	best := workers[0]
+	bestLen := best.queue.len()
+	for _, w := range workers[1:] {
+		if l := w.queue.len(); l < bestLen {
+			best, bestLen = w, l
+		}
 	}
+	return best
 }
 
 // 关闭接收结果的通道
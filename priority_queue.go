@@ -0,0 +1,194 @@
+package routinepool
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// priorityTask 是优先级队列中的一个元素，seq 用于保证同一优先级内的 FIFO 顺序
+type priorityTask struct {
+	task     Task
+	priority int
+	seq      int64
+}
+
+// priorityQueue 是替代原先 taskCh 的任务缓冲结构：按 priority 从高到低出队，
+// 同一 priority 内部按入队顺序（FIFO）出队。容量由 capacity 限定，
+// 队列已满时 push 会阻塞，pushCtx 额外支持被 ctx 取消——这与原先
+// `taskCh chan Task` 提供的背压语义保持一致。maxSkip > 0 时，每连续出队
+// maxSkip 个"存在更低优先级任务等待"的高优先级任务后，强制放行一个当前
+// 排队中优先级最低的任务，避免低优先级任务被饿死。
+type priorityQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	items   []*priorityTask
+	nextSeq int64
+	closed  bool
+
+	maxSkip int
+	skipped int
+
+	// slots 是容量为 capacity 的信号量：每个槽位代表一个可用的排队配额，
+	// push 之前必须先从中取走一个槽位，pop 之后归还
+	slots       chan struct{}
+	closeSignal chan struct{}
+}
+
+// errQueueClosed 在队列已关闭后仍尝试 push 时返回
+var errQueueClosed = fmt.Errorf("the pool is closed")
+
+func newPriorityQueue(capacity int) *priorityQueue {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	q := &priorityQueue{
+		slots:       make(chan struct{}, capacity),
+		closeSignal: make(chan struct{}),
+	}
+	for i := 0; i < capacity; i++ {
+		q.slots <- struct{}{}
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// push 阻塞直到拿到一个排队槽位（或队列被关闭），再把任务放入队列
+func (q *priorityQueue) push(task Task, priority int) bool {
+	select {
+	case <-q.slots:
+	case <-q.closeSignal:
+		return false
+	}
+	return q.enqueue(task, priority)
+}
+
+// pushCtx 与 push 类似，但在等待槽位期间会响应 ctx 的取消
+func (q *priorityQueue) pushCtx(ctx context.Context, task Task, priority int) error {
+	select {
+	case <-q.slots:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-q.closeSignal:
+		return errQueueClosed
+	}
+	if !q.enqueue(task, priority) {
+		return errQueueClosed
+	}
+	return nil
+}
+
+// enqueue 在已经拿到槽位的前提下把任务放入堆中并唤醒等待中的 pop
+func (q *priorityQueue) enqueue(task Task, priority int) bool {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return false
+	}
+	task.enqueuedAt = time.Now()
+	item := &priorityTask{task: task, priority: priority, seq: q.nextSeq}
+	q.nextSeq++
+	heap.Push(q, item)
+	q.cond.Signal()
+	q.mu.Unlock()
+	return true
+}
+
+// pop 阻塞直到队列非空或被 close，返回下一个应当执行的任务，并归还一个槽位
+func (q *priorityQueue) pop() (Task, bool) {
+	q.mu.Lock()
+	for len(q.items) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if len(q.items) == 0 {
+		q.mu.Unlock()
+		return Task{}, false
+	}
+
+	var item *priorityTask
+	if q.maxSkip > 0 && q.skipped >= q.maxSkip {
+		idx := q.lowestPriorityIndex()
+		item = heap.Remove(q, idx).(*priorityTask)
+		q.skipped = 0
+	} else {
+		top := q.items[0]
+		hasLower := q.hasLowerPriorityThan(top.priority)
+		item = heap.Pop(q).(*priorityTask)
+		if hasLower {
+			q.skipped++
+		} else {
+			q.skipped = 0
+		}
+	}
+	q.mu.Unlock()
+
+	q.slots <- struct{}{}
+	return item.task, true
+}
+
+// len 返回当前排队（尚未被 dispatch 取走）的任务数
+func (q *priorityQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// close 标记队列关闭，唤醒所有等待中的 pop/push
+func (q *priorityQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.mu.Unlock()
+	close(q.closeSignal)
+}
+
+// hasLowerPriorityThan 调用方需持有 q.mu
+func (q *priorityQueue) hasLowerPriorityThan(priority int) bool {
+	for _, it := range q.items {
+		if it.priority < priority {
+			return true
+		}
+	}
+	return false
+}
+
+// lowestPriorityIndex 调用方需持有 q.mu
+func (q *priorityQueue) lowestPriorityIndex() int {
+	idx := 0
+	for i, it := range q.items {
+		if it.priority < q.items[idx].priority ||
+			(it.priority == q.items[idx].priority && it.seq < q.items[idx].seq) {
+			idx = i
+		}
+	}
+	return idx
+}
+
+// 以下方法实现 container/heap.Interface，调用方需持有 q.mu
+
+func (q *priorityQueue) Len() int { return len(q.items) }
+
+func (q *priorityQueue) Less(i, j int) bool {
+	if q.items[i].priority != q.items[j].priority {
+		return q.items[i].priority > q.items[j].priority
+	}
+	return q.items[i].seq < q.items[j].seq
+}
+
+func (q *priorityQueue) Swap(i, j int) {
+	q.items[i], q.items[j] = q.items[j], q.items[i]
+}
+
+func (q *priorityQueue) Push(x interface{}) {
+	q.items = append(q.items, x.(*priorityTask))
+}
+
+func (q *priorityQueue) Pop() interface{} {
+	n := len(q.items)
+	item := q.items[n-1]
+	q.items[n-1] = nil
+	q.items = q.items[:n-1]
+	return item
+}
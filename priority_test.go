@@ -0,0 +1,127 @@
+package routinepool
+
+import (
+	"sync"
+	"testing"
+)
+
+// 验证任务按 priority 从高到低出队，同一 priority 内部保持先进先出
+func TestPriorityOrderingWithinSamePriorityIsFIFO(t *testing.T) {
+	pool := NewPool(1, workerQueueCapacity+20)
+	if err := pool.Start(); err != nil {
+		t.Fatalf("start pool: %v", err)
+	}
+	defer pool.Stop()
+
+	_, unblock := stallSingleWorkerQueue(t, pool)
+
+	var mu sync.Mutex
+	var order []int
+	record := func(idx int) {
+		mu.Lock()
+		order = append(order, idx)
+		mu.Unlock()
+	}
+
+	resultFuture := pool.newFuture(4)
+	defer resultFuture.Close()
+
+	submit := func(index, priority int) {
+		if err := pool.AddTaskPriority(resultFuture, index, priority, func() (interface{}, error) {
+			record(index)
+			return nil, nil
+		}); err != nil {
+			t.Fatalf("add priority task: %v", err)
+		}
+	}
+	// 提交顺序故意打乱：期望的执行顺序是按 priority 从高到低，
+	// 同一 priority（这里是两个优先级为 0 的任务）内部保持先进先出
+	submit(0, 0)
+	submit(1, 10)
+	submit(2, 0)
+	submit(3, 5)
+
+	close(unblock)
+
+	for i := 0; i < 4; i++ {
+		<-resultFuture.Result
+	}
+
+	want := []int{1, 3, 0, 2}
+	mu.Lock()
+	got := append([]int(nil), order...)
+	mu.Unlock()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d tasks to run, got %d: %v", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("execution order = %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+// 验证 WithStarvationPrevention 能限制一个低优先级任务最多被饿多少次：
+// 连续放行 maxSkip 个存在更低优先级任务等待的高优先级任务后，必须强制放行它
+func TestStarvationPreventionBoundsSkipCount(t *testing.T) {
+	const maxSkip = 3
+	pool := NewPool(1, workerQueueCapacity+20, WithStarvationPrevention(maxSkip))
+	if err := pool.Start(); err != nil {
+		t.Fatalf("start pool: %v", err)
+	}
+	defer pool.Stop()
+
+	_, unblock := stallSingleWorkerQueue(t, pool)
+
+	var mu sync.Mutex
+	var order []int
+	record := func(idx int) {
+		mu.Lock()
+		order = append(order, idx)
+		mu.Unlock()
+	}
+
+	const highCount = 5
+	resultFuture := pool.newFuture(highCount + 1)
+	defer resultFuture.Close()
+
+	if err := pool.AddTaskPriority(resultFuture, 0, 0, func() (interface{}, error) {
+		record(0)
+		return nil, nil
+	}); err != nil {
+		t.Fatalf("add low-priority task: %v", err)
+	}
+	for i := 1; i <= highCount; i++ {
+		idx := i
+		if err := pool.AddTaskPriority(resultFuture, idx, 10, func() (interface{}, error) {
+			record(idx)
+			return nil, nil
+		}); err != nil {
+			t.Fatalf("add high-priority task %d: %v", idx, err)
+		}
+	}
+
+	close(unblock)
+	for i := 0; i <= highCount; i++ {
+		<-resultFuture.Result
+	}
+
+	mu.Lock()
+	got := append([]int(nil), order...)
+	mu.Unlock()
+
+	pos := -1
+	for i, idx := range got {
+		if idx == 0 {
+			pos = i
+			break
+		}
+	}
+	if pos == -1 {
+		t.Fatalf("low-priority task never ran, order=%v", got)
+	}
+	if pos > maxSkip {
+		t.Errorf("starvation prevention should force the low-priority task to run within %d skips, but it ran at position %d: order=%v", maxSkip, pos, got)
+	}
+}
@@ -0,0 +1,53 @@
+package routinepool
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// 验证 /debug/pool 处理器返回 200，且响应体能解析成 Stats 并与 Stats() 一致
+func TestServeHTTPReturnsStatsAsJSON(t *testing.T) {
+	pool := NewPool(2, 10)
+	if err := pool.Start(); err != nil {
+		t.Fatalf("start pool: %v", err)
+	}
+	defer pool.Stop()
+
+	future := pool.newFuture(1)
+	defer future.Close()
+	if err := pool.AddTask(future, 0, func() (interface{}, error) {
+		return "ok", nil
+	}); err != nil {
+		t.Fatalf("add task: %v", err)
+	}
+	<-future.Result
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/pool", nil)
+	rec := httptest.NewRecorder()
+	pool.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected Content-Type application/json, got %q", ct)
+	}
+
+	var got Stats
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal response body: %v, body=%s", err, rec.Body.String())
+	}
+
+	want := pool.Stats()
+	if got.CompletedTasks != want.CompletedTasks {
+		t.Errorf("CompletedTasks = %d, want %d", got.CompletedTasks, want.CompletedTasks)
+	}
+	if got.FailedTasks != want.FailedTasks {
+		t.Errorf("FailedTasks = %d, want %d", got.FailedTasks, want.FailedTasks)
+	}
+	if got.QueuedTasks != want.QueuedTasks {
+		t.Errorf("QueuedTasks = %d, want %d", got.QueuedTasks, want.QueuedTasks)
+	}
+}
@@ -0,0 +1,49 @@
+package routinepool
+
+import "testing"
+
+// 验证 shrink 在目标 worker 的本地队列非空时拒绝收缩，避免队列里还没被
+// 取走或行窃走的任务，随着 worker 从 p.workers 里消失而再也没人处理
+func TestShrinkRefusesToRemoveAWorkerWithAPendingQueue(t *testing.T) {
+	pool := NewPoolWithBounds(1, 2, 10)
+
+	pool.workersMu.Lock()
+	extra := pool.newWorker()
+	pool.workers = append(pool.workers, extra)
+	pool.workersMu.Unlock()
+
+	if !extra.queue.pushBack(Task{index: 0, fn: func() (interface{}, error) { return nil, nil }}) {
+		t.Fatalf("failed to seed a task into the extra worker's queue")
+	}
+
+	if pool.shrink(extra) {
+		t.Fatalf("expected shrink to refuse removing a worker whose queue is non-empty")
+	}
+	pool.workersMu.Lock()
+	found := false
+	for _, w := range pool.workers {
+		if w == extra {
+			found = true
+			break
+		}
+	}
+	pool.workersMu.Unlock()
+	if !found {
+		t.Fatalf("expected the worker to remain in p.workers after a refused shrink")
+	}
+
+	if _, ok := extra.queue.popFront(); !ok {
+		t.Fatalf("expected to pop the previously seeded task back out")
+	}
+
+	if !pool.shrink(extra) {
+		t.Fatalf("expected shrink to succeed once the worker's queue is empty")
+	}
+	pool.workersMu.Lock()
+	for _, w := range pool.workers {
+		if w == extra {
+			t.Fatalf("expected the worker to be removed from p.workers after a successful shrink")
+		}
+	}
+	pool.workersMu.Unlock()
+}
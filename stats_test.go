@@ -0,0 +1,69 @@
+package routinepool
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// 验证 Stats() 报告的排队、完成、失败数量与协程池的真实状态一致，
+// 既要统计还没被 dispatch 取走的任务，也要统计已经进入失败状态的任务
+func TestStatsReflectCompletedFailedAndQueuedTasks(t *testing.T) {
+	pool := NewPool(1, 100)
+	if err := pool.Start(); err != nil {
+		t.Fatalf("start pool: %v", err)
+	}
+	defer pool.Stop()
+
+	future := pool.newFuture(3)
+	defer future.Close()
+
+	block := make(chan struct{})
+	if err := pool.AddTask(future, 0, func() (interface{}, error) {
+		<-block
+		return nil, nil
+	}); err != nil {
+		t.Fatalf("add blocking task: %v", err)
+	}
+	if err := pool.AddTask(future, 1, func() (interface{}, error) {
+		return nil, fmt.Errorf("boom")
+	}); err != nil {
+		t.Fatalf("add failing task: %v", err)
+	}
+	if err := pool.AddTask(future, 2, func() (interface{}, error) {
+		return "ok", nil
+	}); err != nil {
+		t.Fatalf("add succeeding task: %v", err)
+	}
+
+	// 此时唯一的 worker 被 task0 占着，task1/task2 应当还在排队
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if pool.Stats().QueuedTasks >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for tasks to queue up, stats=%+v", pool.Stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(block)
+	for i := 0; i < 3; i++ {
+		<-future.Result
+	}
+
+	stats := pool.Stats()
+	if stats.CompletedTasks != 2 {
+		t.Errorf("expected 2 completed tasks, got %d", stats.CompletedTasks)
+	}
+	if stats.FailedTasks != 1 {
+		t.Errorf("expected 1 failed task, got %d", stats.FailedTasks)
+	}
+	if stats.QueuedTasks != 0 {
+		t.Errorf("expected QueuedTasks to drain back to 0, got %d", stats.QueuedTasks)
+	}
+	if stats.RunningTasks != 0 {
+		t.Errorf("expected RunningTasks to settle back to 0, got %d", stats.RunningTasks)
+	}
+}
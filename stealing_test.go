@@ -0,0 +1,57 @@
+package routinepool
+
+import (
+	"testing"
+	"time"
+)
+
+// 验证当一个 worker 的本地队列被积压大量任务时，其它空闲 worker 会主动
+// 从其队尾行窃，从而让这些任务仍然能被及时、正确地执行完。这里直接把
+// 任务塞进某个 worker 自己的队列（而不是通过 AddTask），是因为 dispatch
+// 投递时总是挑选负载最小的 worker，正常提交路径本身就会把任务打散到
+// 各个 worker，不会产生需要行窃才能缓解的不均衡场景。
+func TestWorkStealingDrainsAnOverloadedWorkersQueue(t *testing.T) {
+	pool := NewPool(4, 10)
+	if err := pool.Start(); err != nil {
+		t.Fatalf("start pool: %v", err)
+	}
+	defer pool.Stop()
+
+	const n = 40
+	future := pool.newFuture(n)
+	defer future.Close()
+
+	pool.workersMu.Lock()
+	overloaded := pool.workers[0]
+	pool.workersMu.Unlock()
+
+	for i := 0; i < n; i++ {
+		idx := i
+		task := Task{
+			index: idx,
+			fn: func() (interface{}, error) {
+				time.Sleep(time.Millisecond)
+				return idx, nil
+			},
+			responseCh: future.Result,
+		}
+		if !overloaded.queue.pushBack(task) {
+			t.Fatalf("failed to seed task %d directly into the worker's queue", idx)
+		}
+	}
+	overloaded.wake()
+
+	results := make(map[int]Result, n)
+	for i := 0; i < n; i++ {
+		r := <-future.Result
+		results[r.index] = r
+	}
+	for i := 0; i < n; i++ {
+		if results[i].err != nil {
+			t.Errorf("task %d failed: %v", i, results[i].err)
+		}
+	}
+	if pool.Stats().Steals == 0 {
+		t.Errorf("expected other idle workers to steal work from the overloaded worker's queue, got 0 steals")
+	}
+}
@@ -1,31 +1,65 @@
 package routinepool
 
-// worker 工作协程结构体
+import (
+	"fmt"
+	"time"
+)
+
+// stealPollInterval 是 worker 本地队列为空时，轮询尝试行窃的间隔
+const stealPollInterval = 2 * time.Millisecond
+
+// worker 工作协程结构体，每个 worker 拥有自己的本地任务队列
 type worker struct {
-	id        int
-	owner     *Pool
-	requestCh chan Task
-	shutdown  chan struct{}
+	id       int
+	owner    *Pool
+	queue    *taskDeque
+	wakeCh   chan struct{} // 容量为 1，任务入队时用于唤醒正在等待的 worker
+	shutdown chan struct{}
+	steals   int64 // 该 worker 从其它 worker 偷到的任务数，仅由自身协程写入
+}
+
+// wake 在有新任务投递进本 worker 的队列时尝试唤醒它，已有待唤醒信号时不重复发送
+func (w *worker) wake() {
+	select {
+	case w.wakeCh <- struct{}{}:
+	default:
+	}
 }
 
-// start 启动工作协程
+// start 启动工作协程：优先执行自己队列里的任务，队列为空时尝试从其它 worker 处行窃，
+// 两者都没有任务且空闲超过 owner.idleTimeout 后会尝试收缩退出
 func (w *worker) start() {
 	defer w.owner.wg.Done()
+	idleDeadline := time.Now().Add(w.owner.idleTimeout)
 	for {
-		select {
-		case task, ok := <-w.requestCh:
-			if !ok {
-				return
+		task, ok := w.queue.popFront()
+		if !ok {
+			task, ok = w.owner.steal(w)
+			if ok {
+				w.steals++
 			}
-			result, err := task.fn()
-			// 将结果发送到调用方提供的响应通道
-			if task.responseCh != nil {
-				task.responseCh <- Result{
-					index: task.index,
-					value: result,
-					err:   err,
-				}
+		}
+		if ok {
+			idleDeadline = time.Now().Add(w.owner.idleTimeout)
+			w.execute(task)
+			continue
+		}
+
+		wait := time.Until(idleDeadline)
+		if wait <= 0 {
+			if w.owner.shrink(w) {
+				w.owner.idle.Add(-1)
+				return
 			}
+			idleDeadline = time.Now().Add(w.owner.idleTimeout)
+			wait = w.owner.idleTimeout
+		}
+		if wait > stealPollInterval {
+			wait = stealPollInterval
+		}
+		select {
+		case <-w.wakeCh:
+		case <-time.After(wait):
 		case <-w.shutdown:
 			return
 		case <-w.owner.shutdownCh:
@@ -34,6 +68,59 @@ func (w *worker) start() {
 	}
 }
 
+// execute 记录运行态指标、执行任务并把结果投递到调用方的响应通道
+func (w *worker) execute(task Task) {
+	if !task.enqueuedAt.IsZero() {
+		w.owner.waitNanos.Add(int64(time.Since(task.enqueuedAt)))
+	}
+	w.owner.idle.Add(-1)
+	w.owner.running.Add(1)
+	w.owner.affinity.Store(goroutineID(), w)
+	if w.owner.metricsSink != nil {
+		w.owner.metricsSink.OnStart()
+	}
+
+	runStart := time.Now()
+	result, err := w.run(task)
+	w.owner.runNanos.Add(int64(time.Since(runStart)))
+
+	w.owner.affinity.Delete(goroutineID())
+	w.owner.running.Add(-1)
+	w.owner.idle.Add(1)
+	if err != nil {
+		w.owner.failed.Add(1)
+	} else {
+		w.owner.completed.Add(1)
+	}
+	if w.owner.metricsSink != nil {
+		w.owner.metricsSink.OnFinish(err)
+	}
+	if task.responseCh != nil {
+		task.responseCh <- Result{
+			index: task.index,
+			value: result,
+			err:   err,
+		}
+	}
+}
+
+// run 执行任务，捕获 panic 以避免 worker 协程异常退出
+func (w *worker) run(task Task) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			w.owner.panics.Add(1)
+			if w.owner.panicHandler != nil {
+				w.owner.panicHandler(task.index, r)
+			}
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	if task.ctxFn != nil {
+		return task.ctxFn(task.ctx)
+	}
+	return task.fn()
+}
+
 func (w *worker) stop() {
 	close(w.shutdown)
 }